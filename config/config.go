@@ -0,0 +1,119 @@
+// Package config defines the document shape parsed by config/parsing
+// into the core service/listener/handler objects.
+package config
+
+// ServiceConfig describes a single proxy service: a listener accepting
+// connections and a handler that processes them.
+//
+// Version selects the document shape: 0/1 (the default, omitted) keeps
+// the legacy single Addr + Listener behavior; 2 allows Listeners to
+// carry multiple independent binds that all feed the same Handler, via
+// a composite listener. With Version 2 and a non-empty Listeners,
+// Addr/Listener bind nothing; below Version 2, Listeners is ignored.
+type ServiceConfig struct {
+	Name       string
+	Addr       string
+	Interface  string
+	SockOpts   *SockOptsConfig
+	Admission  string
+	Admissions []string
+	Bypass     string
+	Bypasses   []string
+	Resolver   string
+	Hosts      string
+	Limiter    string
+	Listener   *ListenerConfig
+	Handler    *HandlerConfig
+	Forwarder  *ForwarderConfig
+	Recorders  []RecorderConfig
+	Metadata   map[string]any
+
+	Version   int
+	Listeners []*ListenerConfig
+}
+
+// ListenerConfig configures one bind point. In a version-2 service it
+// also carries its own address/network, so a single handler can be
+// exposed on several listeners (e.g. TLS on :443 and plaintext on a
+// loopback admin port) without duplicating the handler config.
+type ListenerConfig struct {
+	Type    string
+	Addr    string
+	Network string // tcp, tcp4, tcp6, unix; read only by ohttp/owss/oh2, which default to tcp (tcp4 for an IPv4 addr)
+
+	TLS           *TLSConfig
+	ProxyProtocol *ProxyProtocolConfig
+	Auther        string
+	Authers       []string
+	Auth          *AuthConfig
+	Admission     string
+	Admissions    []string
+	Limiter       string
+	Chain         string
+	ChainGroup    *ChainGroupConfig
+	Metadata      map[string]any
+}
+
+type HandlerConfig struct {
+	Type       string
+	Retries    int
+	TLS        *TLSConfig
+	Auther     string
+	Authers    []string
+	Auth       *AuthConfig
+	Chain      string
+	ChainGroup *ChainGroupConfig
+	Metadata   map[string]any
+}
+
+type AuthConfig struct {
+	Username string
+	Password string
+}
+
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+type SockOptsConfig struct {
+	Mark int
+}
+
+// ProxyProtocolConfig configures PROXY protocol support on a listener.
+type ProxyProtocolConfig struct {
+	Enabled bool
+	Mode    string // "optional" (default) or "required"
+	CIDRs   []string
+	TLVs    []string
+}
+
+type RecorderConfig struct {
+	Name   string
+	Record string
+}
+
+type ForwarderConfig struct {
+	Targets  []string
+	Nodes    []*ForwarderNodeConfig
+	Selector *SelectorConfig
+}
+
+type ForwarderNodeConfig struct {
+	Name     string
+	Addr     string
+	Bypass   string
+	Bypasses []string
+}
+
+type ChainGroupConfig struct {
+	Chains   []string
+	Selector *SelectorConfig
+}
+
+type SelectorConfig struct {
+	Strategy    string
+	MaxFails    int
+	FailTimeout int
+}
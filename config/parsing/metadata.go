@@ -0,0 +1,29 @@
+package parsing
+
+// Metadata keys for wiring a hierarchy of named rate limiters onto a
+// single listener (see limiter.MultiLimiter). cfg.Limiter remains the
+// service-scope limiter; these add the CIDR-group, per-connection and
+// per-user scopes.
+const (
+	mdKeyLimiterCIDR       = "limiter.cidr"
+	mdKeyLimiterCIDRGroups = "limiter.cidr.groups"
+	mdKeyLimiterConn       = "limiter.conn"
+	mdKeyLimiterUser       = "limiter.user"
+)
+
+// Metadata keys mirrored from listener/obfs/http, used to translate a
+// version-2 ListenerConfig.ProxyProtocol block into the metadata map
+// passed to a listener's Init, so any listener that understands these
+// keys (currently ohttp/owss/oh2) picks up the structured config as-is.
+const (
+	mdKeyProxyProtocolMode  = "proxyProtocol.mode"
+	mdKeyProxyProtocolCIDRs = "proxyProtocol.cidrs"
+	mdKeyProxyProtocolTLVs  = "proxyProtocol.tlvs"
+)
+
+// mdKeyNetwork mirrors ListenerConfig.Network into the metadata map, the
+// same way mdKeyProxyProtocol* does, so a listener type that binds its
+// own net.Listener (currently ohttp/owss/oh2) can honor a version-2
+// entry's "network" (tcp/tcp4/tcp6/unix) instead of always guessing from
+// the address family.
+const mdKeyNetwork = "network"
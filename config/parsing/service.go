@@ -1,6 +1,8 @@
 package parsing
 
 import (
+	"fmt"
+	"net"
 	"strings"
 
 	"github.com/go-gost/core/admission"
@@ -8,14 +10,18 @@ import (
 	"github.com/go-gost/core/bypass"
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
+	climiter "github.com/go-gost/core/limiter"
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
+	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
 	"github.com/go-gost/core/recorder"
 	"github.com/go-gost/core/selector"
 	"github.com/go-gost/core/service"
 	"github.com/go-gost/x/config"
 	tls_util "github.com/go-gost/x/internal/util/tls"
+	xlimiter "github.com/go-gost/x/limiter"
+	xlistener "github.com/go-gost/x/listener"
 	"github.com/go-gost/x/metadata"
 	"github.com/go-gost/x/registry"
 )
@@ -78,6 +84,7 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 
 	var ppv int
 	ifce := cfg.Interface
+	rlimiter := registry.RateLimiterRegistry().Get(cfg.Limiter)
 	if cfg.Metadata != nil {
 		md := metadata.NewMetadata(cfg.Metadata)
 		ppv = mdutil.GetInt(md, mdKeyProxyProtocol)
@@ -89,27 +96,37 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 				Mark: v,
 			}
 		}
+		rlimiter = scopedRateLimiter(rlimiter, md)
 	}
 
-	ln := registry.ListenerRegistry().Get(cfg.Listener.Type)(
-		listener.AddrOption(cfg.Addr),
-		listener.AutherOption(auther),
-		listener.AuthOption(parseAuth(cfg.Listener.Auth)),
-		listener.TLSConfigOption(tlsConfig),
-		listener.AdmissionOption(admission.AdmissionGroup(admissions...)),
-		listener.ChainOption(chainGroup(cfg.Listener.Chain, cfg.Listener.ChainGroup)),
-		listener.RateLimiterOption(registry.RateLimiterRegistry().Get(cfg.Limiter)),
-		listener.LoggerOption(listenerLogger),
-		listener.ServiceOption(cfg.Name),
-		listener.ProxyProtocolOption(ppv),
-	)
-
-	if cfg.Listener.Metadata == nil {
-		cfg.Listener.Metadata = make(map[string]any)
-	}
-	if err := ln.Init(metadata.NewMetadata(cfg.Listener.Metadata)); err != nil {
-		listenerLogger.Error("init: ", err)
-		return nil, err
+	var ln listener.Listener
+	if cfg.Version >= 2 && len(cfg.Listeners) > 0 {
+		ln, err = parseMultiListener(cfg, listenerLogger)
+		if err != nil {
+			listenerLogger.Error("init: ", err)
+			return nil, err
+		}
+	} else {
+		ln = registry.ListenerRegistry().Get(cfg.Listener.Type)(
+			listener.AddrOption(cfg.Addr),
+			listener.AutherOption(auther),
+			listener.AuthOption(parseAuth(cfg.Listener.Auth)),
+			listener.TLSConfigOption(tlsConfig),
+			listener.AdmissionOption(admission.AdmissionGroup(admissions...)),
+			listener.ChainOption(chainGroup(cfg.Listener.Chain, cfg.Listener.ChainGroup)),
+			listener.RateLimiterOption(rlimiter),
+			listener.LoggerOption(listenerLogger),
+			listener.ServiceOption(cfg.Name),
+			listener.ProxyProtocolOption(ppv),
+		)
+
+		if cfg.Listener.Metadata == nil {
+			cfg.Listener.Metadata = make(map[string]any)
+		}
+		if err := ln.Init(metadata.NewMetadata(cfg.Listener.Metadata)); err != nil {
+			listenerLogger.Error("init: ", err)
+			return nil, err
+		}
 	}
 
 	handlerLogger := serviceLogger.WithFields(map[string]any{
@@ -259,6 +276,146 @@ func admissionList(name string, names ...string) []admission.Admission {
 	return admissions
 }
 
+// scopedRateLimiter builds the CIDR-group, per-connection and per-user
+// scopes on top of rlimiter (the existing service-scope limiter named by
+// cfg.Limiter), returning rlimiter unchanged when no additional scope is
+// configured.
+func scopedRateLimiter(rlimiter climiter.RateLimiter, md mdata.Metadata) climiter.RateLimiter {
+	cidrName := mdutil.GetString(md, mdKeyLimiterCIDR)
+	connName := mdutil.GetString(md, mdKeyLimiterConn)
+	userName := mdutil.GetString(md, mdKeyLimiterUser)
+	if cidrName == "" && connName == "" && userName == "" {
+		return rlimiter
+	}
+
+	ml := xlimiter.NewMultiLimiter()
+	ml.Add(xlimiter.ScopeService, rlimiter)
+
+	if cidrName != "" {
+		ml.Add(xlimiter.ScopeCIDR, registry.RateLimiterRegistry().Get(cidrName))
+		for cidr, group := range mdutil.GetStringMapString(md, mdKeyLimiterCIDRGroups) {
+			ml.AddCIDRGroup(cidr, group)
+		}
+	}
+	if connName != "" {
+		ml.Add(xlimiter.ScopeConn, registry.RateLimiterRegistry().Get(connName))
+	}
+	if userName != "" {
+		ml.Add(xlimiter.ScopeUser, registry.RateLimiterRegistry().Get(userName))
+		ml.WithResolver(xlimiter.UserFromContext)
+	}
+
+	return ml
+}
+
+// parseMultiListener builds one net.Listener per entry in cfg.Listeners
+// and fans them into a single xlistener.NewMultiListener, so a version-2
+// service can bind several address/network/TLS/PROXY-protocol/admission
+// /rate-limiter combinations while still presenting one listener.Listener
+// to service.NewService.
+func parseMultiListener(cfg *config.ServiceConfig, listenerLogger logger.Logger) (listener.Listener, error) {
+	var lns []net.Listener
+	for i, entry := range cfg.Listeners {
+		entryLogger := listenerLogger.WithFields(map[string]any{
+			"listener": entry.Type,
+			"addr":     entry.Addr,
+		})
+		ln, err := parseListenerEntry(cfg.Name, entry, entryLogger)
+		if err != nil {
+			return nil, fmt.Errorf("listeners[%d]: %w", i, err)
+		}
+		lns = append(lns, ln)
+	}
+	return xlistener.NewMultiListener(lns...), nil
+}
+
+// parseListenerEntry builds and initializes a single net.Listener from a
+// version-2 listen block. Unlike the legacy cfg.Listener/cfg.Addr path,
+// TLS, admission, rate-limiter and PROXY protocol are all scoped to the
+// entry rather than the service.
+func parseListenerEntry(svcName string, entry *config.ListenerConfig, entryLogger logger.Logger) (listener.Listener, error) {
+	tlsCfg := entry.TLS
+	if tlsCfg == nil {
+		tlsCfg = &config.TLSConfig{}
+	}
+	tlsConfig, err := tls_util.LoadServerConfig(
+		tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = defaultTLSConfig.Clone()
+	}
+
+	authers := autherList(entry.Auther, entry.Authers...)
+	if len(authers) == 0 {
+		if auther := ParseAutherFromAuth(entry.Auth); auther != nil {
+			authers = append(authers, auther)
+		}
+	}
+	var auther auth.Authenticator
+	if len(authers) > 0 {
+		auther = auth.AuthenticatorGroup(authers...)
+	}
+
+	admissions := admissionList(entry.Admission, entry.Admissions...)
+
+	rlimiter := registry.RateLimiterRegistry().Get(entry.Limiter)
+	if entry.Metadata != nil {
+		rlimiter = scopedRateLimiter(rlimiter, metadata.NewMetadata(entry.Metadata))
+	}
+
+	var ppv int
+	if entry.ProxyProtocol != nil && entry.ProxyProtocol.Enabled {
+		ppv = 1
+	}
+
+	ln := registry.ListenerRegistry().Get(entry.Type)(
+		listener.AddrOption(entry.Addr),
+		listener.AutherOption(auther),
+		listener.AuthOption(parseAuth(entry.Auth)),
+		listener.TLSConfigOption(tlsConfig),
+		listener.AdmissionOption(admission.AdmissionGroup(admissions...)),
+		listener.ChainOption(chainGroup(entry.Chain, entry.ChainGroup)),
+		listener.RateLimiterOption(rlimiter),
+		listener.LoggerOption(entryLogger),
+		listener.ServiceOption(svcName),
+		listener.ProxyProtocolOption(ppv),
+	)
+
+	if err := ln.Init(metadata.NewMetadata(listenerEntryMetadata(entry))); err != nil {
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenerEntryMetadata folds the structured, version-2-only ListenerConfig
+// fields (Network, ProxyProtocol) that have no listener.Option equivalent
+// into entry.Metadata, so any listener that understands the corresponding
+// mdKey* convention (currently ohttp/owss/oh2) picks them up from Init as
+// if they'd been set directly in the config file.
+func listenerEntryMetadata(entry *config.ListenerConfig) map[string]any {
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]any)
+	}
+	if entry.Network != "" {
+		entry.Metadata[mdKeyNetwork] = entry.Network
+	}
+	if entry.ProxyProtocol != nil {
+		entry.Metadata[mdKeyProxyProtocol] = entry.ProxyProtocol.Enabled
+		if entry.ProxyProtocol.Mode != "" {
+			entry.Metadata[mdKeyProxyProtocolMode] = entry.ProxyProtocol.Mode
+		}
+		if len(entry.ProxyProtocol.CIDRs) > 0 {
+			entry.Metadata[mdKeyProxyProtocolCIDRs] = entry.ProxyProtocol.CIDRs
+		}
+		if len(entry.ProxyProtocol.TLVs) > 0 {
+			entry.Metadata[mdKeyProxyProtocolTLVs] = entry.ProxyProtocol.TLVs
+		}
+	}
+	return entry.Metadata
+}
+
 func chainGroup(name string, group *config.ChainGroupConfig) chain.Chainer {
 	var chains []chain.Chainer
 	var sel selector.Selector[chain.Chainer]
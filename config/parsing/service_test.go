@@ -0,0 +1,51 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/go-gost/x/config"
+)
+
+func TestListenerEntryMetadataThreadsNetwork(t *testing.T) {
+	entry := &config.ListenerConfig{
+		Addr:    "127.0.0.1:8443",
+		Network: "unix",
+	}
+
+	md := listenerEntryMetadata(entry)
+
+	if got := md[mdKeyNetwork]; got != "unix" {
+		t.Fatalf("metadata[%q] = %v, want %q", mdKeyNetwork, got, "unix")
+	}
+}
+
+func TestListenerEntryMetadataOmitsNetworkWhenUnset(t *testing.T) {
+	entry := &config.ListenerConfig{Addr: "127.0.0.1:8443"}
+
+	md := listenerEntryMetadata(entry)
+
+	if _, ok := md[mdKeyNetwork]; ok {
+		t.Fatalf("metadata[%q] set to %v, want it left out so the listener keeps its own default", mdKeyNetwork, md[mdKeyNetwork])
+	}
+}
+
+func TestListenerEntryMetadataThreadsProxyProtocol(t *testing.T) {
+	entry := &config.ListenerConfig{
+		Addr: "127.0.0.1:8443",
+		ProxyProtocol: &config.ProxyProtocolConfig{
+			Enabled: true,
+			Mode:    "required",
+			CIDRs:   []string{"10.0.0.0/8"},
+		},
+	}
+
+	md := listenerEntryMetadata(entry)
+
+	if md[mdKeyProxyProtocolMode] != "required" {
+		t.Fatalf("metadata[%q] = %v, want %q", mdKeyProxyProtocolMode, md[mdKeyProxyProtocolMode], "required")
+	}
+	cidrs, ok := md[mdKeyProxyProtocolCIDRs].([]string)
+	if !ok || len(cidrs) != 1 || cidrs[0] != "10.0.0.0/8" {
+		t.Fatalf("metadata[%q] = %v, want [10.0.0.0/8]", mdKeyProxyProtocolCIDRs, md[mdKeyProxyProtocolCIDRs])
+	}
+}
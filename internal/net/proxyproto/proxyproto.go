@@ -0,0 +1,432 @@
+// Package proxyproto implements a minimal PROXY protocol v1/v2 listener
+// wrapper with TLV passthrough, so handlers downstream of a listener can
+// recover the original client address (and vendor-specific TLVs such as
+// the AWS VPC endpoint ID or the Azure PRIVATE_ENDPOINT marker) even when
+// the proxy sits behind an L4 load balancer.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Mode controls how a listener reacts to a connection that does not carry
+// a valid PROXY protocol header.
+type Mode string
+
+const (
+	// ModeOptional accepts the connection as-is when no header is present.
+	ModeOptional Mode = "optional"
+	// ModeRequired rejects any connection that doesn't present a valid
+	// PROXY protocol v2 header.
+	ModeRequired Mode = "required"
+)
+
+// TLV types we know how to label. Anything else is kept as raw bytes.
+const (
+	TypeALPN                 = 0x01
+	TypeAuthority            = 0x02
+	TypeAWSVPCEndpointID     = 0xEA
+	TypeAzurePrivateEndpoint = 0xEE
+)
+
+var signatureV2 = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+var (
+	// ErrNoHeader is returned when Mode is required and the connection
+	// did not present a PROXY protocol v2 header.
+	ErrNoHeader = errors.New("proxyproto: no v2 header")
+	// ErrV1NotAllowed is returned when a v1 header is seen but Mode is
+	// required (v1 carries no TLVs and is not trusted for that case).
+	ErrV1NotAllowed = errors.New("proxyproto: v1 header not allowed in required mode")
+)
+
+// TLV is a single Type-Length-Value entry carried by a v2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Option configures the PROXY protocol wrapper for a listener.
+type Option struct {
+	// Enabled turns the wrapper on. When false, WrapListener is a no-op.
+	Enabled bool
+	// Mode is Optional (default) or Required.
+	Mode Mode
+	// CIDRs restricts which upstream peers are trusted to present a
+	// PROXY header at all; connections from other sources are passed
+	// through unmodified. An empty list trusts everyone.
+	CIDRs []string
+	// TLVs is the set of TLV types to keep on Info after a successful
+	// parse; all other TLV types are discarded. A nil/empty list keeps
+	// every TLV encountered.
+	TLVs []byte
+}
+
+func (o Option) mode() Mode {
+	if o.Mode == "" {
+		return ModeOptional
+	}
+	return o.Mode
+}
+
+func (o Option) trusted(addr net.Addr) bool {
+	if len(o.CIDRs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, s := range o.CIDRs {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			continue
+		}
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Option) keep(t byte) bool {
+	if len(o.TLVs) == 0 {
+		return true
+	}
+	for _, v := range o.TLVs {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Header is the information recovered from a PROXY protocol header.
+type Header struct {
+	Version byte
+	Command byte
+	SrcAddr net.Addr
+	DstAddr net.Addr
+	TLVs    []TLV
+}
+
+// infoProvider is implemented by any net.Conn wrapper stacked on top of
+// WrapListener's conn (e.g. one built by an obfuscating listener) that
+// needs to carry the PROXY protocol header past its own wrapping, so Info
+// keeps working after additional layers are added. WrapListener's own
+// conn does not implement it; Info recognizes that type directly.
+type infoProvider interface {
+	ProxyInfo() (*Header, bool)
+}
+
+// Info returns the PROXY protocol header stashed on c, if any.
+func Info(c net.Conn) (*Header, bool) {
+	if pc, ok := c.(*conn); ok {
+		if pc.info == nil {
+			return nil, false
+		}
+		return pc.info, true
+	}
+	if ip, ok := c.(infoProvider); ok {
+		return ip.ProxyInfo()
+	}
+	return nil, false
+}
+
+type conn struct {
+	net.Conn
+	info *Header
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	if c.info != nil && c.info.SrcAddr != nil {
+		return c.info.SrcAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+type listener struct {
+	net.Listener
+	opt     Option
+	timeout time.Duration
+
+	once   sync.Once
+	connCh chan net.Conn
+	errCh  chan error
+}
+
+// WrapListener wraps ln so that Accept parses an optional PROXY protocol
+// v1/v2 header off the front of each new connection, stashing the result
+// (source address and any preserved TLVs) on the returned net.Conn, which
+// callers can retrieve with Info(c).
+func WrapListener(opt Option, ln net.Listener, timeout time.Duration) net.Listener {
+	if !opt.Enabled {
+		return ln
+	}
+	return &listener{
+		Listener: ln,
+		opt:      opt,
+		timeout:  timeout,
+		connCh:   make(chan net.Conn),
+		errCh:    make(chan error, 1),
+	}
+}
+
+// run accepts off the underlying listener in its own goroutine and hands
+// each raw conn to parseAsync, so that one slow or stalled peer parsing its
+// PROXY header can't hold up l.Listener.Accept() for everyone else; Accept
+// itself only ever blocks on connCh/errCh.
+func (l *listener) run() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			l.errCh <- err
+			return
+		}
+		go l.parseAsync(c)
+	}
+}
+
+func (l *listener) parseAsync(c net.Conn) {
+	if !l.opt.trusted(c.RemoteAddr()) {
+		l.connCh <- c
+		return
+	}
+
+	nc, err := l.parse(c)
+	if err != nil {
+		// Whether Mode is Optional or Required, a peer that triggers a
+		// genuine parse error (malformed v2 header, or a deadline hit
+		// while reading a v1 line) is just a bad connection, not a
+		// reason to tear down the listener for every other peer.
+		c.Close()
+		return
+	}
+	l.connCh <- nc
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	l.once.Do(func() { go l.run() })
+
+	select {
+	case c := <-l.connCh:
+		return c, nil
+	case err := <-l.errCh:
+		return nil, err
+	}
+}
+
+func (l *listener) parse(c net.Conn) (net.Conn, error) {
+	if l.timeout > 0 {
+		c.SetReadDeadline(time.Now().Add(l.timeout))
+		defer c.SetReadDeadline(time.Time{})
+	}
+
+	br := bufio.NewReader(c)
+	sig, err := br.Peek(len(signatureV2))
+	if err == nil && bytes.Equal(sig, signatureV2) {
+		info, err := readV2(br)
+		if err != nil {
+			return nil, err
+		}
+		return &conn{Conn: &bufReadConn{Conn: c, r: br}, info: l.filter(info)}, nil
+	}
+
+	// A v1 header always starts with "PROXY ". Peeking that fixed prefix
+	// before committing to the blocking ReadString below means ordinary
+	// non-PROXY traffic (whose first flight may never contain a '\n', e.g.
+	// a binary protocol) returns immediately instead of sitting on the
+	// read deadline for every optional-mode connection.
+	prefix, err := br.Peek(6)
+	if err == nil && string(prefix) == "PROXY " {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if l.opt.mode() == ModeRequired {
+			return nil, ErrV1NotAllowed
+		}
+		info := parseV1(line)
+		return &conn{Conn: &bufReadConn{Conn: c, r: br}, info: info}, nil
+	}
+
+	if l.opt.mode() == ModeRequired {
+		return nil, ErrNoHeader
+	}
+
+	// no header: rewind by keeping whatever we've already buffered.
+	return &bufReadConn{Conn: c, r: br}, nil
+}
+
+func (l *listener) filter(info *Header) *Header {
+	if info == nil || len(info.TLVs) == 0 {
+		return info
+	}
+	kept := info.TLVs[:0]
+	for _, t := range info.TLVs {
+		if l.opt.keep(t.Type) {
+			kept = append(kept, t)
+		}
+	}
+	info.TLVs = kept
+	return info
+}
+
+// bufReadConn lets Read go through a bufio.Reader that may already hold
+// bytes peeked/consumed while looking for a PROXY header, while every
+// other net.Conn method still goes straight to the underlying conn.
+type bufReadConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufReadConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func readV2(br *bufio.Reader) (*Header, error) {
+	hdr := make([]byte, len(signatureV2)+4)
+	if _, err := readFull(br, hdr); err != nil {
+		return nil, err
+	}
+
+	verCmd := hdr[12]
+	famProto := hdr[13]
+	length := binary.BigEndian.Uint16(hdr[14:16])
+
+	body := make([]byte, length)
+	if _, err := readFull(br, body); err != nil {
+		return nil, err
+	}
+
+	info := &Header{
+		Version: verCmd >> 4,
+		Command: verCmd & 0x0F,
+	}
+
+	family := famProto >> 4
+	addrLen := 0
+	switch family {
+	case 0x1: // AF_INET
+		addrLen = 12
+	case 0x2: // AF_INET6
+		addrLen = 36
+	}
+
+	if addrLen > 0 && len(body) >= addrLen {
+		switch family {
+		case 0x1:
+			srcIP := net.IP(body[0:4])
+			dstIP := net.IP(body[4:8])
+			srcPort := binary.BigEndian.Uint16(body[8:10])
+			dstPort := binary.BigEndian.Uint16(body[10:12])
+			info.SrcAddr = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+			info.DstAddr = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+		case 0x2:
+			srcIP := net.IP(body[0:16])
+			dstIP := net.IP(body[16:32])
+			srcPort := binary.BigEndian.Uint16(body[32:34])
+			dstPort := binary.BigEndian.Uint16(body[34:36])
+			info.SrcAddr = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+			info.DstAddr = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+		}
+		body = body[addrLen:]
+	}
+
+	for len(body) >= 3 {
+		t := body[0]
+		l := binary.BigEndian.Uint16(body[1:3])
+		body = body[3:]
+		if int(l) > len(body) {
+			break
+		}
+		info.TLVs = append(info.TLVs, TLV{Type: t, Value: body[:l]})
+		body = body[l:]
+	}
+
+	return info, nil
+}
+
+func parseV1(line string) *Header {
+	// "PROXY TCP4 <src> <dst> <srcport> <dstport>\r\n"
+	fields := make([]string, 0, 6)
+	start := 0
+	for i := 0; i < len(line); i++ {
+		if line[i] == ' ' || line[i] == '\r' || line[i] == '\n' {
+			if i > start {
+				fields = append(fields, line[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if len(fields) < 6 {
+		return &Header{Version: 1}
+	}
+	return &Header{
+		Version: 1,
+		SrcAddr: &net.TCPAddr{IP: net.ParseIP(fields[2])},
+		DstAddr: &net.TCPAddr{IP: net.ParseIP(fields[3])},
+	}
+}
+
+func readFull(br *bufio.Reader, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		nn, err := br.Read(b[n:])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// WrapClientConn writes a PROXY protocol v2 header (with the given TLVs)
+// to c before any application data, for use on the dial side of a
+// forwarder that needs to pass the original client address along.
+func WrapClientConn(c net.Conn, src, dst net.Addr, tlvs []TLV) error {
+	var body bytes.Buffer
+
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+	famProto := byte(0x11) // TCP over IPv4 by default
+
+	if srcOK && dstOK && srcTCP.IP.To4() != nil {
+		body.Write(srcTCP.IP.To4())
+		body.Write(dstTCP.IP.To4())
+		binary.Write(&body, binary.BigEndian, uint16(srcTCP.Port))
+		binary.Write(&body, binary.BigEndian, uint16(dstTCP.Port))
+	} else if srcOK && dstOK {
+		famProto = 0x21 // TCP over IPv6
+		body.Write(srcTCP.IP.To16())
+		body.Write(dstTCP.IP.To16())
+		binary.Write(&body, binary.BigEndian, uint16(srcTCP.Port))
+		binary.Write(&body, binary.BigEndian, uint16(dstTCP.Port))
+	}
+
+	for _, t := range tlvs {
+		body.WriteByte(t.Type)
+		binary.Write(&body, binary.BigEndian, uint16(len(t.Value)))
+		body.Write(t.Value)
+	}
+
+	var hdr bytes.Buffer
+	hdr.Write(signatureV2)
+	hdr.WriteByte(0x21) // version 2, command PROXY
+	hdr.WriteByte(famProto)
+	binary.Write(&hdr, binary.BigEndian, uint16(body.Len()))
+	hdr.Write(body.Bytes())
+
+	_, err := c.Write(hdr.Bytes())
+	return err
+}
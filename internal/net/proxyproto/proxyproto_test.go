@@ -0,0 +1,141 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeListener hands out pre-made net.Pipe server conns one at a time from
+// Accept, standing in for a real net.Listener in tests that exercise the
+// async accept/parse path.
+type fakeListener struct {
+	net.Listener
+	conns chan net.Conn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	c, ok := <-l.conns
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return c, nil
+}
+
+func TestWrapClientConnAndReadV2RoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1111}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2222}
+	tlvs := []TLV{{Type: TypeAuthority, Value: []byte("example.com")}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- WrapClientConn(client, src, dst, tlvs)
+	}()
+
+	ln := &listener{opt: Option{Enabled: true}}
+	nc, err := ln.parse(server)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("WrapClientConn: %v", err)
+	}
+
+	info, ok := Info(nc)
+	if !ok {
+		t.Fatal("Info: expected header, got none")
+	}
+	if info.Version != 2 {
+		t.Fatalf("Version = %d, want 2", info.Version)
+	}
+	if info.SrcAddr.String() != src.String() {
+		t.Fatalf("SrcAddr = %v, want %v", info.SrcAddr, src)
+	}
+	if len(info.TLVs) != 1 || info.TLVs[0].Type != TypeAuthority || string(info.TLVs[0].Value) != "example.com" {
+		t.Fatalf("TLVs = %+v, want authority=example.com", info.TLVs)
+	}
+}
+
+func TestParseV1(t *testing.T) {
+	h := parseV1("PROXY TCP4 192.168.0.1 192.168.0.2 56324 443\r\n")
+	if h.Version != 1 {
+		t.Fatalf("Version = %d, want 1", h.Version)
+	}
+	if h.SrcAddr.(*net.TCPAddr).IP.String() != "192.168.0.1" {
+		t.Fatalf("SrcAddr = %v, want 192.168.0.1", h.SrcAddr)
+	}
+}
+
+func TestParseOptionalModeDoesNotBlockOnNonProxyTraffic(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// a binary payload long enough to satisfy the v2 signature Peek, with
+	// no '\n' anywhere in it: a buggy implementation that unconditionally
+	// calls br.ReadString('\n') after the v2 check fails would block here
+	// until the read deadline fires.
+	go client.Write([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0xFA, 0xFB, 0xFC, 0xFD, 0xFE, 0xFF, 0x10})
+
+	ln := &listener{opt: Option{Enabled: true}, timeout: time.Hour}
+	start := time.Now()
+	nc, err := ln.parse(server)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("parse took %v for non-PROXY traffic, want it to return promptly", elapsed)
+	}
+	if _, ok := Info(nc); ok {
+		t.Fatal("Info: expected no header for plain traffic")
+	}
+}
+
+// TestAcceptDoesNotBlockOnAStalledPeer guards against a regression where
+// Accept() parsed each connection's header inline before returning it: a
+// peer that never finishes its header (or never sends anything at all)
+// would hold up every other accepted connection until the read deadline
+// fired. With the header parse moved off the accept path, a second,
+// well-behaved connection must be returned well before the stalled one's
+// deadline.
+func TestAcceptDoesNotBlockOnAStalledPeer(t *testing.T) {
+	stalledClient, stalledServer := net.Pipe()
+	defer stalledClient.Close()
+	defer stalledServer.Close()
+
+	okClient, okServer := net.Pipe()
+	defer okClient.Close()
+	defer okServer.Close()
+	go okClient.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.2 56324 443\r\n"))
+
+	fl := &fakeListener{conns: make(chan net.Conn, 2)}
+	fl.conns <- stalledServer
+	fl.conns <- okServer
+
+	ln := WrapListener(Option{Enabled: true}, fl, time.Hour)
+
+	done := make(chan struct{})
+	var nc net.Conn
+	var err error
+	go func() {
+		nc, err = ln.Accept()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Accept blocked on a stalled peer instead of returning the next ready connection")
+	}
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	info, ok := Info(nc)
+	if !ok || info.Version != 1 {
+		t.Fatalf("Info = %+v, %v, want a v1 header", info, ok)
+	}
+}
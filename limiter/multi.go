@@ -0,0 +1,261 @@
+// Package limiter composes the per-key core/limiter.RateLimiter
+// primitives into a hierarchy of scopes, so a single connection can be
+// throttled against a global service limit, a per-CIDR-group limit, a
+// per-IP limit, a per-connection limit and a per-user limit all at once.
+package limiter
+
+import (
+	"context"
+	"net"
+	"time"
+
+	climiter "github.com/go-gost/core/limiter"
+)
+
+// Scope identifies one level of the rate-limiting hierarchy. Scopes are
+// applied independently and the effective allowance for a Read/Write is
+// the minimum granted across every scope that has a limiter configured.
+type Scope int
+
+const (
+	// ScopeService is the single, global limiter for the whole service.
+	ScopeService Scope = iota
+	// ScopeCIDR limits traffic from a configured address group.
+	ScopeCIDR
+	// ScopeHost limits traffic per remote IP.
+	ScopeHost
+	// ScopeConn limits traffic per individual connection.
+	ScopeConn
+	// ScopeUser limits traffic per authenticated user, once the auth
+	// layer has identified the peer.
+	ScopeUser
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeService:
+		return "service"
+	case ScopeCIDR:
+		return "cidr"
+	case ScopeHost:
+		return "host"
+	case ScopeConn:
+		return "conn"
+	case ScopeUser:
+		return "user"
+	default:
+		return "unknown"
+	}
+}
+
+// Keys carries the per-scope lookup keys for a single connection. Host
+// is filled in from the remote address when left blank; Conn is filled
+// in with a process-unique id by wrapper.WrapConnScoped when left blank,
+// since several connections commonly share a Host but must still be
+// limited independently at ScopeConn. A blank CIDR is looked up from
+// Host among the groups added with AddCIDRGroup.
+type Keys struct {
+	CIDR string
+	Host string
+	Conn string
+}
+
+type userKey struct{}
+
+// ContextWithUser returns a context carrying user as the identity
+// UserFromContext (the canned ScopeResolver wired by config/parsing when
+// a per-user limiter is configured) resolves ScopeUser's key from.
+func ContextWithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext is a ScopeResolver that reads back the user stashed by
+// ContextWithUser. Once a connection's auth layer identifies the peer it
+// calls the connection's SetContext (see wrapper.WrapConnScoped) with a
+// context built from ContextWithUser, making the user visible to every
+// WaitIn/WaitOut call after that point.
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok && user != ""
+}
+
+// ScopeResolver resolves the ScopeUser key for a connection from its
+// context, once the handler's auth layer has authenticated the peer. It
+// returns ok=false when no user is known yet, in which case the user
+// scope is skipped for that call.
+type ScopeResolver func(ctx context.Context) (user string, ok bool)
+
+// Metrics receives the effect of an enforcement decision at a given
+// scope, for callers that want to feed limiter_dropped_bytes_total /
+// limiter_wait_seconds style counters.
+type Metrics interface {
+	AddDroppedBytes(scope Scope, n int)
+	ObserveWait(scope Scope, d time.Duration)
+}
+
+// MultiLimiter composes one climiter.RateLimiter per Scope. It also
+// implements climiter.RateLimiter itself (resolving the service,
+// CIDR-group and host scopes keyed by remote IP, plus the user scope
+// when the context passed to Wait carries a user), so it can be used
+// anywhere a single limiter.RateLimiter is expected; the per-connection
+// scope is resolved only by WaitIn/WaitOut callers that set Keys.Conn.
+type MultiLimiter struct {
+	limiters   map[Scope]climiter.RateLimiter
+	cidrGroups []cidrGroup
+	resolver   ScopeResolver
+	metrics    Metrics
+}
+
+// cidrGroup pairs one CIDR with the name of the group it belongs to.
+type cidrGroup struct {
+	ipnet *net.IPNet
+	name  string
+}
+
+func NewMultiLimiter() *MultiLimiter {
+	return &MultiLimiter{
+		limiters: make(map[Scope]climiter.RateLimiter),
+	}
+}
+
+// Add registers rl for scope. A nil rl is ignored, so callers can wire
+// optional scopes unconditionally.
+func (m *MultiLimiter) Add(scope Scope, rl climiter.RateLimiter) *MultiLimiter {
+	if rl == nil {
+		return m
+	}
+	m.limiters[scope] = rl
+	return m
+}
+
+func (m *MultiLimiter) WithResolver(resolver ScopeResolver) *MultiLimiter {
+	m.resolver = resolver
+	return m
+}
+
+func (m *MultiLimiter) WithMetrics(metrics Metrics) *MultiLimiter {
+	m.metrics = metrics
+	return m
+}
+
+// AddCIDRGroup names the address group cidr belongs to, so connections
+// from it are also rate-limited under the ScopeCIDR limiter.
+func (m *MultiLimiter) AddCIDRGroup(cidr, name string) *MultiLimiter {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return m
+	}
+	m.cidrGroups = append(m.cidrGroups, cidrGroup{ipnet: ipnet, name: name})
+	return m
+}
+
+func (m *MultiLimiter) cidrGroupFor(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	for _, g := range m.cidrGroups {
+		if g.ipnet.Contains(ip) {
+			return g.name
+		}
+	}
+	return ""
+}
+
+// In implements climiter.RateLimiter, enforcing the service/cidr/host
+// scopes keyed by host.
+func (m *MultiLimiter) In(host string) climiter.Limiter {
+	return &hostLimiter{m: m, host: host, out: false}
+}
+
+// Out implements climiter.RateLimiter, enforcing the service/cidr/host
+// scopes keyed by host.
+func (m *MultiLimiter) Out(host string) climiter.Limiter {
+	return &hostLimiter{m: m, host: host, out: true}
+}
+
+type hostLimiter struct {
+	m    *MultiLimiter
+	host string
+	out  bool
+}
+
+func (h *hostLimiter) Wait(ctx context.Context, n int) int {
+	keys := Keys{Host: h.host, CIDR: h.m.cidrGroupFor(h.host)}
+	if h.out {
+		return h.m.WaitOut(ctx, keys, n)
+	}
+	return h.m.WaitIn(ctx, keys, n)
+}
+
+// WaitIn enforces the inbound (read) direction across every configured
+// scope and returns the number of the n requested bytes that may pass.
+func (m *MultiLimiter) WaitIn(ctx context.Context, keys Keys, n int) int {
+	return m.wait(ctx, keys, n, climiter.RateLimiter.In)
+}
+
+// WaitOut enforces the outbound (write) direction across every
+// configured scope and returns the number of the n requested bytes that
+// may pass.
+func (m *MultiLimiter) WaitOut(ctx context.Context, keys Keys, n int) int {
+	return m.wait(ctx, keys, n, climiter.RateLimiter.Out)
+}
+
+func (m *MultiLimiter) wait(ctx context.Context, keys Keys, n int, pick func(climiter.RateLimiter, string) climiter.Limiter) int {
+	if m == nil || len(m.limiters) == 0 {
+		return n
+	}
+
+	allowed := n
+	for _, scope := range []Scope{ScopeService, ScopeCIDR, ScopeHost, ScopeConn, ScopeUser} {
+		rl, ok := m.limiters[scope]
+		if !ok {
+			continue
+		}
+		key, ok := m.keyFor(ctx, scope, keys)
+		if !ok {
+			continue
+		}
+		lim := pick(rl, key)
+		if lim == nil {
+			continue
+		}
+
+		start := time.Now()
+		w := lim.Wait(ctx, allowed)
+		if m.metrics != nil {
+			m.metrics.ObserveWait(scope, time.Since(start))
+			if w < allowed {
+				m.metrics.AddDroppedBytes(scope, allowed-w)
+			}
+		}
+		if w < allowed {
+			allowed = w
+		}
+	}
+	return allowed
+}
+
+func (m *MultiLimiter) keyFor(ctx context.Context, scope Scope, keys Keys) (string, bool) {
+	switch scope {
+	case ScopeService:
+		return "", true
+	case ScopeCIDR:
+		cidr := keys.CIDR
+		if cidr == "" {
+			cidr = m.cidrGroupFor(keys.Host)
+		}
+		return cidr, cidr != ""
+	case ScopeHost:
+		return keys.Host, keys.Host != ""
+	case ScopeConn:
+		return keys.Conn, keys.Conn != ""
+	case ScopeUser:
+		if m.resolver == nil {
+			return "", false
+		}
+		return m.resolver(ctx)
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	climiter "github.com/go-gost/core/limiter"
+)
+
+// capLimiter caps Wait at max, regardless of n, emulating a token-bucket
+// limiter that's run dry.
+type capLimiter struct{ max int }
+
+func (l *capLimiter) Wait(ctx context.Context, n int) int {
+	if n > l.max {
+		return l.max
+	}
+	return n
+}
+
+type capRateLimiter struct{ max int }
+
+func (r *capRateLimiter) In(host string) climiter.Limiter  { return &capLimiter{max: r.max} }
+func (r *capRateLimiter) Out(host string) climiter.Limiter { return &capLimiter{max: r.max} }
+
+type fakeMetrics struct {
+	waits   map[Scope]int
+	dropped map[Scope]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{waits: make(map[Scope]int), dropped: make(map[Scope]int)}
+}
+
+func (m *fakeMetrics) ObserveWait(scope Scope, d time.Duration) { m.waits[scope]++ }
+func (m *fakeMetrics) AddDroppedBytes(scope Scope, n int)       { m.dropped[scope] += n }
+
+func TestMultiLimiterAppliesTightestScope(t *testing.T) {
+	ml := NewMultiLimiter().
+		Add(ScopeService, &capRateLimiter{max: 1000}).
+		Add(ScopeCIDR, &capRateLimiter{max: 500}).
+		Add(ScopeHost, &capRateLimiter{max: 200})
+	ml.AddCIDRGroup("10.0.0.0/8", "internal")
+
+	n := ml.WaitIn(context.Background(), Keys{Host: "10.0.0.5", CIDR: ml.cidrGroupFor("10.0.0.5")}, 1000)
+	if n != 200 {
+		t.Fatalf("WaitIn = %d, want 200 (tightest scope wins)", n)
+	}
+}
+
+func TestMultiLimiterScopeConnIsIndependentPerConnection(t *testing.T) {
+	ml := NewMultiLimiter().Add(ScopeConn, &capRateLimiter{max: 10})
+
+	a := ml.WaitIn(context.Background(), Keys{Host: "1.2.3.4", Conn: "conn-a"}, 100)
+	b := ml.WaitIn(context.Background(), Keys{Host: "1.2.3.4", Conn: "conn-b"}, 100)
+	if a != 10 || b != 10 {
+		t.Fatalf("WaitIn = (%d, %d), want (10, 10)", a, b)
+	}
+}
+
+func TestMultiLimiterScopeUserRequiresResolver(t *testing.T) {
+	ml := NewMultiLimiter().Add(ScopeUser, &capRateLimiter{max: 10})
+
+	// no resolver configured: ScopeUser must be skipped, not block forever
+	// or panic, and the full n must pass through.
+	if n := ml.WaitIn(context.Background(), Keys{Host: "1.2.3.4"}, 100); n != 100 {
+		t.Fatalf("WaitIn without resolver = %d, want 100 (scope skipped)", n)
+	}
+
+	ml.WithResolver(UserFromContext)
+	ctx := ContextWithUser(context.Background(), "alice")
+	if n := ml.WaitIn(ctx, Keys{Host: "1.2.3.4"}, 100); n != 10 {
+		t.Fatalf("WaitIn with resolved user = %d, want 10", n)
+	}
+	if n := ml.WaitIn(context.Background(), Keys{Host: "1.2.3.4"}, 100); n != 100 {
+		t.Fatalf("WaitIn without a user in ctx = %d, want 100 (scope skipped)", n)
+	}
+}
+
+func TestMultiLimiterMetricsObserveEveryScope(t *testing.T) {
+	m := newFakeMetrics()
+	ml := NewMultiLimiter().
+		Add(ScopeService, &capRateLimiter{max: 50}).
+		WithMetrics(m)
+
+	if n := ml.WaitIn(context.Background(), Keys{Host: "1.2.3.4"}, 100); n != 50 {
+		t.Fatalf("WaitIn = %d, want 50", n)
+	}
+	if m.waits[ScopeService] != 1 {
+		t.Fatalf("ObserveWait calls for ScopeService = %d, want 1", m.waits[ScopeService])
+	}
+	if m.dropped[ScopeService] != 50 {
+		t.Fatalf("AddDroppedBytes for ScopeService = %d, want 50", m.dropped[ScopeService])
+	}
+}
@@ -4,19 +4,31 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/go-gost/core/limiter"
 	xnet "github.com/go-gost/x/internal/net"
 	"github.com/go-gost/x/internal/net/udp"
+	xlimiter "github.com/go-gost/x/limiter"
 )
 
 var (
 	errUnsupport = errors.New("unsupported operation")
 )
 
+// connSeq hands out the process-unique suffix WrapConnScoped uses to
+// fill in Keys.Conn when the caller leaves it blank.
+var connSeq uint64
+
+func nextConnKey() string {
+	return fmt.Sprintf("conn-%d", atomic.AddUint64(&connSeq, 1))
+}
+
 // serverConn is a server side Conn with metrics supported.
 type serverConn struct {
 	net.Conn
@@ -338,3 +350,201 @@ func (c *udpConn) SetDSCP(n int) error {
 	}
 	return nil
 }
+
+// scopedConn is a server side Conn rate-limited by a hierarchy of scopes
+// (global service -> CIDR group -> per-IP -> per-connection -> per-user)
+// via an xlimiter.MultiLimiter; the effective allowance for a Read/Write
+// is the minimum granted across every scope that has a limiter configured.
+type scopedConn struct {
+	net.Conn
+	rbuf    bytes.Buffer
+	keys    xlimiter.Keys
+	limiter *xlimiter.MultiLimiter
+
+	mu  sync.RWMutex
+	ctx context.Context
+}
+
+// WrapConnScoped is the hierarchy-aware counterpart of WrapConn: it
+// throttles through ml instead of a single limiter.RateLimiter.
+func WrapConnScoped(ml *xlimiter.MultiLimiter, c net.Conn, keys xlimiter.Keys) net.Conn {
+	if ml == nil {
+		return c
+	}
+	if keys.Host == "" {
+		keys.Host, _, _ = net.SplitHostPort(c.RemoteAddr().String())
+	}
+	if keys.Conn == "" {
+		keys.Conn = nextConnKey()
+	}
+	return &scopedConn{
+		Conn:    c,
+		keys:    keys,
+		limiter: ml,
+		ctx:     context.Background(),
+	}
+}
+
+// SetContext replaces the context WaitIn/WaitOut are called with, so a
+// caller that authenticates the peer after Accept (e.g. a handler's auth
+// step) can make the identity visible to ScopeUser's resolver via
+// xlimiter.ContextWithUser, without having to thread a context through
+// the net.Conn Read/Write interface.
+func (c *scopedConn) SetContext(ctx context.Context) {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+}
+
+func (c *scopedConn) context() context.Context {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ctx
+}
+
+func (c *scopedConn) Read(b []byte) (n int, err error) {
+	if c.rbuf.Len() > 0 {
+		burst := len(b)
+		if c.rbuf.Len() < burst {
+			burst = c.rbuf.Len()
+		}
+		lim := c.limiter.WaitIn(c.context(), c.keys, burst)
+		return c.rbuf.Read(b[:lim])
+	}
+
+	nn, err := c.Conn.Read(b)
+	if err != nil {
+		return nn, err
+	}
+
+	n = c.limiter.WaitIn(c.context(), c.keys, nn)
+	if n < nn {
+		if _, err = c.rbuf.Write(b[n:nn]); err != nil {
+			return 0, err
+		}
+	}
+
+	return
+}
+
+func (c *scopedConn) Write(b []byte) (n int, err error) {
+	nn := 0
+	for len(b) > 0 {
+		allowed := c.limiter.WaitOut(c.context(), c.keys, len(b))
+		nn, err = c.Conn.Write(b[:allowed])
+		n += nn
+		if err != nil {
+			return
+		}
+		b = b[nn:]
+	}
+
+	return
+}
+
+func (c *scopedConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
+
+// scopedPacketConn is the hierarchy-aware counterpart of packetConn.
+type scopedPacketConn struct {
+	net.PacketConn
+	keys    xlimiter.Keys
+	limiter *xlimiter.MultiLimiter
+}
+
+func WrapPacketConnScoped(ml *xlimiter.MultiLimiter, pc net.PacketConn, keys xlimiter.Keys) net.PacketConn {
+	if ml == nil {
+		return pc
+	}
+	return &scopedPacketConn{
+		PacketConn: pc,
+		keys:       keys,
+		limiter:    ml,
+	}
+}
+
+func (c *scopedPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return
+		}
+
+		keys := c.keys
+		keys.Host, _, _ = net.SplitHostPort(addr.String())
+		// discard when exceed the limit size.
+		if c.limiter.WaitIn(context.Background(), keys, n) < n {
+			continue
+		}
+
+		return
+	}
+}
+
+func (c *scopedPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	keys := c.keys
+	keys.Host, _, _ = net.SplitHostPort(addr.String())
+	// discard when exceed the limit size.
+	if c.limiter.WaitOut(context.Background(), keys, len(p)) < len(p) {
+		n = len(p)
+		return
+	}
+
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// scopedUDPConn is the hierarchy-aware counterpart of udpConn. It
+// embeds an unthrottled udpConn and overrides only ReadFrom and WriteTo;
+// every other method, including Read, Write, ReadFromUDP, ReadMsgUDP,
+// WriteToUDP and WriteMsgUDP, goes to the embedded udpConn and is not
+// rate-limited.
+type scopedUDPConn struct {
+	*udpConn
+	keys    xlimiter.Keys
+	limiter *xlimiter.MultiLimiter
+}
+
+func WrapUDPConnScoped(ml *xlimiter.MultiLimiter, pc net.PacketConn, keys xlimiter.Keys) udp.Conn {
+	return &scopedUDPConn{
+		udpConn: &udpConn{PacketConn: pc},
+		keys:    keys,
+		limiter: ml,
+	}
+}
+
+func (c *scopedUDPConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return
+		}
+
+		keys := c.keys
+		keys.Host, _, _ = net.SplitHostPort(addr.String())
+		// discard when exceed the limit size.
+		if c.limiter.WaitIn(context.Background(), keys, n) < n {
+			continue
+		}
+
+		return
+	}
+}
+
+func (c *scopedUDPConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	keys := c.keys
+	keys.Host, _, _ = net.SplitHostPort(addr.String())
+	// discard when exceed the limit size.
+	if c.limiter.WaitOut(context.Background(), keys, len(p)) < len(p) {
+		n = len(p)
+		return
+	}
+
+	n, err = c.PacketConn.WriteTo(p, addr)
+	return
+}
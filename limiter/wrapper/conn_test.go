@@ -0,0 +1,86 @@
+package wrapper
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	climiter "github.com/go-gost/core/limiter"
+	xlimiter "github.com/go-gost/x/limiter"
+)
+
+type capLimiter struct{ max int }
+
+func (l *capLimiter) Wait(ctx context.Context, n int) int {
+	if n > l.max {
+		return l.max
+	}
+	return n
+}
+
+type capRateLimiter struct{ max int }
+
+func (r *capRateLimiter) In(host string) climiter.Limiter  { return &capLimiter{max: r.max} }
+func (r *capRateLimiter) Out(host string) climiter.Limiter { return &capLimiter{max: r.max} }
+
+func TestWrapConnScopedFillsConnKey(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ml := xlimiter.NewMultiLimiter().Add(xlimiter.ScopeConn, &capRateLimiter{max: 4})
+	wc := WrapConnScoped(ml, server, xlimiter.Keys{})
+
+	sc, ok := wc.(*scopedConn)
+	if !ok {
+		t.Fatalf("WrapConnScoped returned %T, want *scopedConn", wc)
+	}
+	if sc.keys.Conn == "" {
+		t.Fatal("keys.Conn left blank, want an auto-filled per-connection id")
+	}
+
+	go client.Write([]byte("hello world"))
+	buf := make([]byte, 11)
+	n, err := wc.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("Read returned %d bytes, want 4 (capped by ScopeConn)", n)
+	}
+}
+
+func TestScopedConnSetContextAffectsUserScope(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ml := xlimiter.NewMultiLimiter().
+		Add(xlimiter.ScopeUser, &capRateLimiter{max: 4}).
+		WithResolver(xlimiter.UserFromContext)
+	wc := WrapConnScoped(ml, server, xlimiter.Keys{})
+
+	sc := wc.(*scopedConn)
+	sc.SetContext(xlimiter.ContextWithUser(context.Background(), "alice"))
+
+	done := make(chan struct{})
+	go func() {
+		client.Write([]byte("hello world"))
+		close(done)
+	}()
+
+	buf := make([]byte, 11)
+	n, err := wc.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("Read returned %d bytes, want 4 (capped by ScopeUser once user is set)", n)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client write never completed")
+	}
+}
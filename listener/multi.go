@@ -0,0 +1,111 @@
+// Package listener provides listener-level helpers shared across the
+// concrete listener implementations under listener/<type>.
+package listener
+
+import (
+	"net"
+	"sync"
+
+	corelistener "github.com/go-gost/core/listener"
+	"github.com/go-gost/core/metadata"
+)
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// multiListener fans Accept in from several inner listeners, so a
+// single service can be bound to several address/network/TLS/PROXY-
+// protocol/admission/limiter combinations (a version-2 "listeners:"
+// block) while presenting one net.Listener to the service.
+type multiListener struct {
+	lns    []net.Listener
+	connCh chan acceptResult
+	closed chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewMultiListener wraps lns as a core listener.Listener. Each entry in
+// lns must already be initialized (Init called) individually; Init on
+// the returned value is a no-op. Closing it closes every inner listener.
+func NewMultiListener(lns ...net.Listener) corelistener.Listener {
+	ml := &multiListener{
+		lns:    lns,
+		connCh: make(chan acceptResult),
+		closed: make(chan struct{}),
+	}
+	ml.wg.Add(len(lns))
+	for _, ln := range lns {
+		go ml.acceptLoop(ln)
+	}
+	// Only once every inner listener has stopped accepting is the
+	// composite actually done; surface that as the terminal error
+	// instead of letting a single inner listener's exit end Accept for
+	// all the others.
+	go func() {
+		ml.wg.Wait()
+		select {
+		case ml.connCh <- acceptResult{err: net.ErrClosed}:
+		case <-ml.closed:
+		}
+	}()
+	return ml
+}
+
+func (ml *multiListener) acceptLoop(ln net.Listener) {
+	defer ml.wg.Done()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			// A temporary error (e.g. a transient accept-queue issue)
+			// is worth retrying; anything else means this particular
+			// listener is done for good, but the remaining listeners
+			// keep accepting independently, so it isn't forwarded as
+			// a fatal error for the whole composite.
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		select {
+		case ml.connCh <- acceptResult{conn: c}:
+		case <-ml.closed:
+			c.Close()
+			return
+		}
+	}
+}
+
+func (ml *multiListener) Init(metadata.Metadata) error {
+	return nil
+}
+
+func (ml *multiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-ml.connCh:
+		return r.conn, r.err
+	case <-ml.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (ml *multiListener) Close() error {
+	ml.once.Do(func() { close(ml.closed) })
+
+	var err error
+	for _, ln := range ml.lns {
+		if e := ln.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (ml *multiListener) Addr() net.Addr {
+	if len(ml.lns) == 0 {
+		return nil
+	}
+	return ml.lns[0].Addr()
+}
@@ -0,0 +1,124 @@
+package listener
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	corelistener "github.com/go-gost/core/listener"
+)
+
+// fakeListener is a minimal net.Listener whose Accept is driven entirely
+// by the test: conns feeds successful accepts, errs feeds accept errors.
+type fakeListener struct {
+	conns  chan net.Conn
+	errs   chan error
+	closed chan struct{}
+}
+
+func newFakeListener() *fakeListener {
+	return &fakeListener{
+		conns:  make(chan net.Conn, 1),
+		errs:   make(chan error, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case err := <-l.errs:
+		return nil, err
+	}
+}
+
+func (l *fakeListener) Close() error {
+	select {
+	case <-l.closed:
+	default:
+		close(l.closed)
+	}
+	return nil
+}
+
+func (l *fakeListener) Addr() net.Addr { return nil }
+
+func mustAccept(t *testing.T, ml corelistener.Listener) (net.Conn, error) {
+	t.Helper()
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := ml.Accept()
+		done <- result{c, err}
+	}()
+	select {
+	case r := <-done:
+		return r.c, r.err
+	case <-time.After(time.Second):
+		t.Fatal("Accept did not return in time")
+		return nil, nil
+	}
+}
+
+func TestMultiListenerIsolatesPerListenerErrors(t *testing.T) {
+	bad := newFakeListener()
+	bad.errs <- errors.New("listener A: accept: connection reset")
+
+	good := newFakeListener()
+	client, server := net.Pipe()
+	defer client.Close()
+	good.conns <- server
+
+	ml := NewMultiListener(bad, good)
+	defer ml.Close()
+
+	c, err := mustAccept(t, ml)
+	if err != nil {
+		t.Fatalf("Accept: %v, want the conn from the still-healthy listener", err)
+	}
+	if c != server {
+		t.Fatalf("Accept returned %v, want the conn queued on the healthy listener", c)
+	}
+}
+
+func TestMultiListenerReturnsErrClosedOnceEveryInnerListenerStops(t *testing.T) {
+	a := newFakeListener()
+	a.errs <- errors.New("listener A: accept: use of closed network connection")
+	b := newFakeListener()
+	b.errs <- errors.New("listener B: accept: use of closed network connection")
+
+	ml := NewMultiListener(a, b)
+	defer ml.Close()
+
+	_, err := mustAccept(t, ml)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept err = %v, want net.ErrClosed once both inner listeners are done", err)
+	}
+}
+
+func TestMultiListenerCloseClosesInnerListeners(t *testing.T) {
+	a := newFakeListener()
+	b := newFakeListener()
+
+	ml := NewMultiListener(a, b)
+	if err := ml.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for name, l := range map[string]*fakeListener{"a": a, "b": b} {
+		select {
+		case <-l.closed:
+		default:
+			t.Fatalf("inner listener %s was not closed", name)
+		}
+	}
+
+	if _, err := ml.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after Close err = %v, want net.ErrClosed", err)
+	}
+}
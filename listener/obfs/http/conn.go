@@ -0,0 +1,89 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/textproto"
+
+	"github.com/go-gost/core/logger"
+)
+
+// obfsHTTPConn disguises the connection as a single plain HTTP request/
+// response exchange: the first Write on each side is preceded by a
+// synthetic HTTP request (client) or response (server) line plus
+// headers, and the matching Read strips that header back off. Nothing
+// past the first packet is touched, so the wrapped conn behaves like a
+// raw stream once the handshake has been exchanged.
+type obfsHTTPConn struct {
+	net.Conn
+	header   map[string]string
+	logger   logger.Logger
+	isClient bool
+
+	rbuf       bytes.Buffer
+	headerRead bool
+	headerSent bool
+}
+
+func (c *obfsHTTPConn) Read(b []byte) (n int, err error) {
+	if !c.headerRead {
+		if err = c.readHeader(); err != nil {
+			return 0, err
+		}
+		c.headerRead = true
+	}
+	if c.rbuf.Len() > 0 {
+		return c.rbuf.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *obfsHTTPConn) readHeader() error {
+	br := bufio.NewReader(c.Conn)
+	tp := textproto.NewReader(br)
+
+	// request line (server side) or status line (client side).
+	if _, err := tp.ReadLine(); err != nil {
+		return err
+	}
+	if _, err := tp.ReadMIMEHeader(); err != nil {
+		return err
+	}
+
+	if n := br.Buffered(); n > 0 {
+		buf := make([]byte, n)
+		if _, err := br.Read(buf); err != nil {
+			return err
+		}
+		c.rbuf.Write(buf)
+	}
+	return nil
+}
+
+func (c *obfsHTTPConn) Write(b []byte) (n int, err error) {
+	if !c.headerSent {
+		if err = c.writeHeader(); err != nil {
+			return 0, err
+		}
+		c.headerSent = true
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *obfsHTTPConn) writeHeader() error {
+	var buf bytes.Buffer
+	if c.isClient {
+		buf.WriteString("GET / HTTP/1.1\r\n")
+	} else {
+		buf.WriteString("HTTP/1.1 200 OK\r\n")
+	}
+	for k, v := range c.header {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	buf.WriteString("\r\n")
+
+	_, err := c.Conn.Write(buf.Bytes())
+	return err
+}
@@ -8,44 +8,68 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	admission "github.com/go-gost/x/admission/wrapper"
+	xlimiter "github.com/go-gost/x/limiter"
 	limiter "github.com/go-gost/x/limiter/wrapper"
 	metrics "github.com/go-gost/x/metrics/wrapper"
 
 	xnet "github.com/go-gost/x/internal/net"
 	"github.com/go-gost/x/internal/net/proxyproto"
+	"github.com/go-gost/x/listener/obfs"
 	"github.com/go-gost/x/registry"
 )
 
 func init() {
-	registry.ListenerRegistry().Register("ohttp", NewListener)
+	registry.ListenerRegistry().Register("ohttp", newListener(modePlain))
+	registry.ListenerRegistry().Register("owss", newListener(modeWebSocket))
+	registry.ListenerRegistry().Register("oh2", newListener(modeHTTP2))
 }
 
 type obfsListener struct {
 	net.Listener
-	logger  logger.Logger
-	md      metadata
-	options listener.Options
+	logger        logger.Logger
+	md            metadata
+	options       listener.Options
+	defaultMode   string
+	obfuscator    obfs.Obfuscator
+	scopedLimiter *xlimiter.MultiLimiter
 }
 
-func NewListener(opts ...listener.Option) listener.Listener {
-	options := listener.Options{}
-	for _, opt := range opts {
-		opt(&options)
-	}
-	return &obfsListener{
-		logger:  options.Logger,
-		options: options,
+// newListener returns a listener.NewListener bound to defaultMode, used
+// when a service doesn't set the "mode" metadata key explicitly. This is
+// how "ohttp", "owss" and "oh2" share the same listener with different
+// out-of-the-box behavior.
+func newListener(defaultMode string) func(opts ...listener.Option) listener.Listener {
+	return func(opts ...listener.Option) listener.Listener {
+		options := listener.Options{}
+		for _, opt := range opts {
+			opt(&options)
+		}
+		return &obfsListener{
+			logger:      options.Logger,
+			options:     options,
+			defaultMode: defaultMode,
+		}
 	}
 }
 
+func NewListener(opts ...listener.Option) listener.Listener {
+	return newListener(modePlain)(opts...)
+}
+
 func (l *obfsListener) Init(md md.Metadata) (err error) {
 	if err = l.parseMetadata(md); err != nil {
 		return
 	}
 
-	network := "tcp"
-	if xnet.IsIPv4(l.options.Addr) {
-		network = "tcp4"
+	// network defaults to the legacy tcp/tcp4-by-address-family guess; a
+	// version-2 listener entry can pin it explicitly (e.g. "unix" or
+	// "tcp6") via the "network" metadata key, see config/parsing.
+	network := l.md.network
+	if network == "" {
+		network = "tcp"
+		if xnet.IsIPv4(l.options.Addr) {
+			network = "tcp4"
+		}
 	}
 	ln, err := net.Listen(network, l.options.Addr)
 	if err != nil {
@@ -53,8 +77,19 @@ func (l *obfsListener) Init(md md.Metadata) (err error) {
 	}
 	ln = metrics.WrapListener(l.options.Service, ln)
 	ln = admission.WrapListener(l.options.Admission, ln)
-	ln = limiter.WrapListener(l.options.RateLimiter, ln)
-	ln = proxyproto.WrapListener(l.options.ProxyProtocol, ln, 10*time.Second)
+	// A *xlimiter.MultiLimiter also happens to satisfy the plain
+	// climiter.RateLimiter interface limiter.WrapListener expects, but
+	// going through that path keys every scope off the remote host and
+	// never calls WaitIn/WaitOut with a per-connection context, so
+	// ScopeConn/ScopeUser can never resolve. Wrap each accepted
+	// connection explicitly instead, so the full scope hierarchy and the
+	// per-connection context (see scopedConn.SetContext) are reachable.
+	if ml, ok := l.options.RateLimiter.(*xlimiter.MultiLimiter); ok {
+		l.scopedLimiter = ml
+	} else {
+		ln = limiter.WrapListener(l.options.RateLimiter, ln)
+	}
+	ln = proxyproto.WrapListener(l.md.proxyProtocol, ln, 10*time.Second)
 
 	l.Listener = ln
 	return
@@ -65,10 +100,61 @@ func (l *obfsListener) Accept() (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	info, _ := proxyproto.Info(c)
+	if l.scopedLimiter != nil {
+		c = limiter.WrapConnScoped(l.scopedLimiter, c, xlimiter.Keys{})
+	}
 
-	return &obfsHTTPConn{
-		Conn:   c,
-		header: l.md.header,
-		logger: l.logger,
-	}, nil
+	ob := l.obfuscator
+	if l.md.mode == modeAuto {
+		mode, pc, err := peekMode(c)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = pc
+		ob = obfsRegistry.Get(mode)
+		if cfg, ok := ob.(interface {
+			Configure(md.Metadata) error
+		}); ok {
+			if err := cfg.Configure(l.md.raw); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+	}
+	if ob == nil {
+		ob = &plainObfuscator{header: l.md.header}
+	}
+
+	wc, err := ob.WrapServer(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if info != nil {
+		wc = &proxyInfoConn{Conn: wc, info: info}
+	}
+	return wc, nil
+}
+
+// proxyInfoConn carries the PROXY protocol header recovered before
+// obfuscation so it survives past the obfuscator's own conn wrapping;
+// callers downstream of the listener recover it with proxyproto.Info,
+// which unwraps ProxyInfo the same way net.Conn wrappers unwrap
+// syscall.Conn.
+type proxyInfoConn struct {
+	net.Conn
+	info *proxyproto.Header
+}
+
+func (c *proxyInfoConn) ProxyInfo() (*proxyproto.Header, bool) {
+	return c.info, true
+}
+
+func (c *proxyInfoConn) RemoteAddr() net.Addr {
+	if c.info != nil && c.info.SrcAddr != nil {
+		return c.info.SrcAddr
+	}
+	return c.Conn.RemoteAddr()
 }
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/go-gost/x/internal/net/proxyproto"
+)
+
+const (
+	mdKeyHeader   = "header"
+	mdKeyMode     = "mode"
+	mdKeyNetwork  = "network"
+	mdKeyWSPath   = "ws.path"
+	mdKeyWSOrigin = "ws.origin"
+
+	mdKeyProxyProtocol      = "proxyProtocol"
+	mdKeyProxyProtocolMode  = "proxyProtocol.mode"
+	mdKeyProxyProtocolCIDRs = "proxyProtocol.cidrs"
+	mdKeyProxyProtocolTLVs  = "proxyProtocol.tlvs"
+)
+
+type metadata struct {
+	header        map[string]string
+	mode          string
+	network       string
+	proxyProtocol proxyproto.Option
+	raw           mdata.Metadata
+}
+
+func (l *obfsListener) parseMetadata(md mdata.Metadata) (err error) {
+	l.md.raw = md
+	l.md.header = mdutil.GetStringMapString(md, mdKeyHeader)
+
+	l.md.mode = mdutil.GetString(md, mdKeyMode)
+	if l.md.mode == "" {
+		l.md.mode = l.defaultMode
+	}
+
+	l.md.network = mdutil.GetString(md, mdKeyNetwork)
+
+	l.md.proxyProtocol = proxyproto.Option{
+		Enabled: mdutil.GetBool(md, mdKeyProxyProtocol),
+		Mode:    proxyproto.Mode(mdutil.GetString(md, mdKeyProxyProtocolMode)),
+		CIDRs:   mdutil.GetStrings(md, mdKeyProxyProtocolCIDRs),
+	}
+	for _, s := range mdutil.GetStrings(md, mdKeyProxyProtocolTLVs) {
+		t, err := parseTLVType(s)
+		if err != nil {
+			return err
+		}
+		l.md.proxyProtocol.TLVs = append(l.md.proxyProtocol.TLVs, t)
+	}
+
+	ob := obfsRegistry.Get(l.md.mode)
+	if ob == nil && l.md.mode != modeAuto {
+		return fmt.Errorf("obfs/http: unknown mode %q", l.md.mode)
+	}
+	if c, ok := ob.(interface {
+		Configure(mdata.Metadata) error
+	}); ok {
+		if err := c.Configure(md); err != nil {
+			return err
+		}
+	}
+	l.obfuscator = ob
+
+	return
+}
+
+// known TLV type aliases, in addition to bare numeric/hex values.
+var tlvAliases = map[string]byte{
+	"alpn":                   proxyproto.TypeALPN,
+	"authority":              proxyproto.TypeAuthority,
+	"aws-vpc-endpoint-id":    proxyproto.TypeAWSVPCEndpointID,
+	"azure-private-endpoint": proxyproto.TypeAzurePrivateEndpoint,
+}
+
+func parseTLVType(s string) (byte, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if t, ok := tlvAliases[s]; ok {
+		return t, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid proxy protocol TLV type %q", s)
+	}
+	return byte(v), nil
+}
@@ -0,0 +1,321 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/go-gost/x/listener/obfs"
+)
+
+// profile names selectable via the "mode" metadata key.
+const (
+	modeAuto        = "auto"
+	modePlain       = "plain"
+	modeWebSocket   = "ws"
+	modeHTTP2       = "h2"
+	modeFingerprint = "fingerprint"
+)
+
+// obfsRegistry is shared by the ohttp/owss/oh2 listener registrations.
+// It is not exported, so profiles are registered on it only from within
+// this package.
+var obfsRegistry = obfs.NewRegistry()
+
+func init() {
+	obfsRegistry.Register(modePlain, func() obfs.Obfuscator { return &plainObfuscator{} })
+	obfsRegistry.Register(modeWebSocket, func() obfs.Obfuscator { return &wsObfuscator{path: "/", origin: ""} })
+	obfsRegistry.Register(modeHTTP2, func() obfs.Obfuscator { return &h2Obfuscator{} })
+	obfsRegistry.Register(modeFingerprint, func() obfs.Obfuscator { return &fingerprintObfuscator{} })
+}
+
+// plainObfuscator mimics a bare HTTP GET/POST exchange. This is the
+// original, single-profile ohttp behavior.
+type plainObfuscator struct {
+	header map[string]string
+}
+
+func (o *plainObfuscator) Configure(md mdata.Metadata) error {
+	o.header = mdutil.GetStringMapString(md, mdKeyHeader)
+	return nil
+}
+
+func (o *plainObfuscator) WrapServer(c net.Conn) (net.Conn, error) {
+	return &obfsHTTPConn{Conn: c, header: o.header}, nil
+}
+
+func (o *plainObfuscator) WrapClient(c net.Conn) (net.Conn, error) {
+	return &obfsHTTPConn{Conn: c, header: o.header, isClient: true}, nil
+}
+
+// wsObfuscator performs a WebSocket upgrade handshake so the first
+// packet looks like a real WS client/server; payload bytes after the
+// handshake are passed through unframed.
+type wsObfuscator struct {
+	path   string
+	origin string
+}
+
+func (o *wsObfuscator) Configure(md mdata.Metadata) error {
+	if v := mdutil.GetString(md, mdKeyWSPath); v != "" {
+		o.path = v
+	}
+	o.origin = mdutil.GetString(md, mdKeyWSOrigin)
+	return nil
+}
+
+func (o *wsObfuscator) WrapServer(c net.Conn) (net.Conn, error) {
+	br := bufio.NewReader(c)
+	tp := textproto.NewReader(br)
+	if _, err := tp.ReadLine(); err != nil { // "GET <path> HTTP/1.1"
+		return nil, err
+	}
+	hdr, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := wsAcceptKey(hdr.Get("Sec-WebSocket-Key"))
+	resp := fmt.Sprintf(
+		"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		accept,
+	)
+	if _, err := c.Write([]byte(resp)); err != nil {
+		return nil, err
+	}
+
+	return &bufferedConn{Conn: c, br: br}, nil
+}
+
+func (o *wsObfuscator) WrapClient(c net.Conn) (net.Conn, error) {
+	key := wsRandomKey()
+	req := fmt.Sprintf(
+		"GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nOrigin: %s\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		o.path, c.RemoteAddr().String(), o.origin, key,
+	)
+	if _, err := c.Write([]byte(req)); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(c)
+	tp := textproto.NewReader(br)
+	if _, err := tp.ReadLine(); err != nil { // "HTTP/1.1 101 Switching Protocols"
+		return nil, err
+	}
+	if _, err := tp.ReadMIMEHeader(); err != nil {
+		return nil, err
+	}
+
+	return &bufferedConn{Conn: c, br: br}, nil
+}
+
+func wsRandomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+const wsMagicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsMagicGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// h2Obfuscator mimics an HTTP/2 prior-knowledge handshake: the fixed
+// connection preface followed by an (empty) SETTINGS frame.
+type h2Obfuscator struct{}
+
+var h2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// an empty SETTINGS frame: 9-byte frame header, length 0, type 0x4.
+var h2EmptySettings = []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// the SETTINGS ACK frame: same header with the ACK flag (0x1) set.
+var h2SettingsAck = []byte{0x00, 0x00, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+func (o *h2Obfuscator) Configure(mdata.Metadata) error { return nil }
+
+func (o *h2Obfuscator) WrapServer(c net.Conn) (net.Conn, error) {
+	buf := make([]byte, len(h2Preface)+len(h2EmptySettings))
+	if _, err := readFull(c, buf); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buf[:len(h2Preface)], h2Preface) {
+		return nil, fmt.Errorf("obfs/http: bad HTTP/2 preface")
+	}
+	if _, err := c.Write(h2SettingsAck); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (o *h2Obfuscator) WrapClient(c net.Conn) (net.Conn, error) {
+	if _, err := c.Write(append(append([]byte{}, h2Preface...), h2EmptySettings...)); err != nil {
+		return nil, err
+	}
+	ack := make([]byte, len(h2SettingsAck))
+	if _, err := readFull(c, ack); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func readFull(c net.Conn, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		nn, err := c.Read(b[n:])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// fingerprintObfuscator picks a bundled browser header profile at random
+// so repeated connections don't all look identical to a passive
+// fingerprinter. The profile's header names (with their casing) and
+// values are sent, but through a map, so the profile's header order is
+// not kept; the Host entry has no value and is not sent.
+type fingerprintObfuscator struct{}
+
+func (o *fingerprintObfuscator) Configure(mdata.Metadata) error { return nil }
+
+type browserProfile struct {
+	name    string
+	headers [][2]string
+}
+
+var browserProfiles = []browserProfile{
+	{
+		name: "chrome",
+		headers: [][2]string{
+			{"Host", ""},
+			{"Connection", "keep-alive"},
+			{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+		},
+	},
+	{
+		name: "firefox",
+		headers: [][2]string{
+			{"Host", ""},
+			{"User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Accept-Language", "en-US,en;q=0.5"},
+			{"Connection", "keep-alive"},
+		},
+	},
+	{
+		name: "safari",
+		headers: [][2]string{
+			{"Host", ""},
+			{"User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Connection", "keep-alive"},
+		},
+	},
+}
+
+func randomBrowserProfile() browserProfile {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(browserProfiles))))
+	if err != nil {
+		return browserProfiles[0]
+	}
+	return browserProfiles[n.Int64()]
+}
+
+func (o *fingerprintObfuscator) WrapServer(c net.Conn) (net.Conn, error) {
+	return &obfsHTTPConn{Conn: c, header: nil}, nil
+}
+
+func (o *fingerprintObfuscator) WrapClient(c net.Conn) (net.Conn, error) {
+	p := randomBrowserProfile()
+	header := make(map[string]string, len(p.headers))
+	for _, kv := range p.headers {
+		if kv[1] == "" {
+			continue
+		}
+		header[kv[0]] = kv[1]
+	}
+	return &obfsHTTPConn{Conn: c, header: header, isClient: true}, nil
+}
+
+// bufferedConn lets Read continue from a bufio.Reader that may have
+// buffered bytes past the handshake it was used to parse.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.br.Read(b)
+}
+
+// peekModeWindow is the number of bytes peekMode looks at when guessing
+// a profile. It needs to cover a full request line plus the
+// "Upgrade: websocket" header, which rarely falls within the first
+// handful of bytes.
+const peekModeWindow = 512
+
+// peekMode inspects the first bytes of a freshly accepted conn to guess
+// which profile it's speaking, for listeners configured with mode=auto.
+// It reads ahead from c into a buffer; callers must use the returned
+// conn (which replays the peeked bytes) instead of c.
+//
+// fingerprintObfuscator is indistinguishable from plainObfuscator on the
+// wire server-side (both just read an arbitrary HTTP request and pass
+// the body through), so auto-detection only discriminates ws, h2 and
+// plain; a detected "plain" is configured identically to an explicit
+// mode=plain.
+func peekMode(c net.Conn) (string, net.Conn, error) {
+	br := bufio.NewReaderSize(c, peekModeWindow)
+	peek, err := br.Peek(peekModeWindow)
+	if err != nil && len(peek) == 0 {
+		return "", nil, err
+	}
+
+	mode := modePlain
+	switch {
+	case hasH2Preface(peek):
+		mode = modeHTTP2
+	case isWebSocketUpgrade(peek):
+		mode = modeWebSocket
+	case bytes.Contains(peek, []byte("GET ")), bytes.Contains(peek, []byte("POST ")):
+		mode = modePlain
+	}
+
+	return mode, &bufferedConn{Conn: c, br: br}, nil
+}
+
+// hasH2Preface reports whether peek starts with h2Preface, or is itself a
+// (possibly truncated) prefix of it. peek can be shorter or longer than
+// h2Preface depending on how much the client has written so far, so the
+// comparison is bounded to whichever is shorter before slicing.
+func hasH2Preface(peek []byte) bool {
+	n := len(h2Preface)
+	if len(peek) < n {
+		n = len(peek)
+	}
+	return bytes.Equal(peek[:n], h2Preface[:n])
+}
+
+// isWebSocketUpgrade reports whether peek (the start of an HTTP request)
+// carries the "Upgrade: websocket" header that marks a real WS handshake.
+func isWebSocketUpgrade(peek []byte) bool {
+	if !bytes.Contains(peek, []byte("GET ")) {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(peek), []byte("upgrade: websocket"))
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net"
+	"testing"
+)
+
+func peekModeFor(t *testing.T, request string) string {
+	t.Helper()
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		client.Write([]byte(request))
+		client.Close()
+		close(done)
+	}()
+
+	mode, _, err := peekMode(server)
+	<-done
+	server.Close()
+	if err != nil {
+		t.Fatalf("peekMode: %v", err)
+	}
+	return mode
+}
+
+func TestPeekModeDetectsHTTP2Preface(t *testing.T) {
+	if mode := peekModeFor(t, string(h2Preface)); mode != modeHTTP2 {
+		t.Fatalf("mode = %q, want %q", mode, modeHTTP2)
+	}
+}
+
+func TestPeekModeDetectsWebSocketUpgrade(t *testing.T) {
+	req := "GET /chat HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if mode := peekModeFor(t, req); mode != modeWebSocket {
+		t.Fatalf("mode = %q, want %q", mode, modeWebSocket)
+	}
+}
+
+func TestPeekModeDefaultsToPlainForOrdinaryHTTP(t *testing.T) {
+	req := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	if mode := peekModeFor(t, req); mode != modePlain {
+		t.Fatalf("mode = %q, want %q", mode, modePlain)
+	}
+}
+
+func TestIsWebSocketUpgradeIgnoresNonGetRequests(t *testing.T) {
+	if isWebSocketUpgrade([]byte("POST /upgrade HTTP/1.1\r\nUpgrade: websocket\r\n\r\n")) {
+		t.Fatal("isWebSocketUpgrade matched a non-GET request")
+	}
+}
+
+// TestHasH2PrefaceHandlesPeekLongerThanPreface guards against a regression
+// where peekModeWindow (512 bytes) exceeds len(h2Preface) (24 bytes): a
+// naive bytes.HasPrefix(peek, h2Preface[:len(peek)]) panics by slicing
+// h2Preface out of range whenever peek carries more than 24 bytes.
+func TestHasH2PrefaceHandlesPeekLongerThanPreface(t *testing.T) {
+	peek := append(append([]byte{}, h2Preface...), make([]byte, peekModeWindow-len(h2Preface))...)
+	if !hasH2Preface(peek) {
+		t.Fatal("hasH2Preface = false for a peek starting with h2Preface")
+	}
+}
+
+func TestHasH2PrefaceRejectsNonMatchingLongPeek(t *testing.T) {
+	peek := make([]byte, peekModeWindow)
+	copy(peek, "GET / HTTP/1.1\r\n")
+	if hasH2Preface(peek) {
+		t.Fatal("hasH2Preface = true for a peek that doesn't start with h2Preface")
+	}
+}
@@ -0,0 +1,66 @@
+// Package obfs defines the pluggable obfuscation profile used by
+// listeners (and their dial-side counterparts) that disguise the wire
+// shape of a connection so passive inspection sees ordinary-looking
+// traffic (HTTP, WebSocket, HTTP/2, ...).
+package obfs
+
+import (
+	"net"
+
+	"github.com/go-gost/core/metadata"
+)
+
+// Obfuscator wraps a raw net.Conn on the accept side (WrapServer) or the
+// dial side (WrapClient) so the two ends of the same profile can talk to
+// each other.
+type Obfuscator interface {
+	WrapServer(net.Conn) (net.Conn, error)
+	WrapClient(net.Conn) (net.Conn, error)
+}
+
+// Configurable is implemented by profiles that take per-listener
+// metadata (header values, WS upgrade fields, fingerprint selection...).
+type Configurable interface {
+	Configure(md metadata.Metadata) error
+}
+
+// Constructor returns a freshly, default-configured Obfuscator instance.
+type Constructor func() Obfuscator
+
+// Registry is a process-wide set of named obfuscation profiles that
+// listeners select between via their "mode" metadata. Third parties can
+// register additional profiles with Register.
+type Registry struct {
+	m map[string]Constructor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		m: make(map[string]Constructor),
+	}
+}
+
+func (r *Registry) Register(name string, ctor Constructor) {
+	if r.m == nil {
+		r.m = make(map[string]Constructor)
+	}
+	r.m[name] = ctor
+}
+
+// Get returns a new Obfuscator instance for name, or nil if name isn't
+// registered.
+func (r *Registry) Get(name string) Obfuscator {
+	ctor, ok := r.m[name]
+	if !ok {
+		return nil
+	}
+	return ctor()
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.m))
+	for name := range r.m {
+		names = append(names, name)
+	}
+	return names
+}